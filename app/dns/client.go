@@ -0,0 +1,199 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	stdnet "net"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// nameServerClient pairs a configured NameServer with the transport client
+// used to reach it and the rules that decide whether it is responsible for
+// a given domain.
+type nameServerClient struct {
+	tag           string
+	queryStrategy QueryStrategy
+	skipFallback  bool
+	domains       *StaticHosts // nil if this server has no PrioritizedDomain rules
+	transport     Client
+}
+
+// newNameServerClient builds the routing rules and transport for a single
+// configured NameServer.
+func newNameServerClient(ns *NameServer) (*nameServerClient, error) {
+	var domains *StaticHosts
+	if len(ns.PrioritizedDomain) > 0 {
+		matcher, err := NewStaticHosts(domainRulesToHostMappings(ns.PrioritizedDomain))
+		if err != nil {
+			return nil, newError("failed to build domain matcher for nameserver: ", ns.Address).Base(err)
+		}
+		domains = matcher
+	}
+
+	transport, err := buildTransportClient(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nameServerClient{
+		tag:           ns.Tag,
+		queryStrategy: ns.QueryStrategy,
+		skipFallback:  ns.SkipFallback,
+		domains:       domains,
+		transport:     transport,
+	}, nil
+}
+
+// handles reports whether this server claims authority over domain via its
+// own PrioritizedDomain rules.
+func (c *nameServerClient) handles(domain string) bool {
+	return c.domains != nil && matchesDomain(c.domains, domain)
+}
+
+// bracketIPv6 wraps host in "[]" if it's an IPv6 literal not already
+// bracketed, as required wherever it's paired with a ":port" or used as a
+// URL authority.
+func bracketIPv6(host string) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// endpointTarget formats a net.Endpoint as the "host:port" string the
+// transport clients dial, bracketing IPv6 literals.
+func endpointTarget(ep *net.Endpoint) string {
+	return fmt.Sprintf("%s:%d", bracketIPv6(ep.Address.String()), ep.Port)
+}
+
+// dialerForNameServer resolves ns.Tag through DialerForTag, so a tagged
+// nameserver's queries are routed through the outbound that tag names,
+// falling back to defaultDialer when ns has no tag or none is registered.
+// Local always wins: a "+local" scheme means dial directly regardless of
+// any tag, which is the whole point of that suffix.
+func dialerForNameServer(ns *NameServer) Dialer {
+	if ns.Local {
+		return defaultDialer
+	}
+	if ns.Tag != "" && DialerForTag != nil {
+		if dial := DialerForTag(ns.Tag); dial != nil {
+			return dial
+		}
+	}
+	return defaultDialer
+}
+
+// buildTransportClient constructs the Client that carries queries to ns,
+// picking the implementation that matches its configured transport.
+func buildTransportClient(ns *NameServer) (Client, error) {
+	if ns.Address == nil {
+		return nil, newError("nameserver has no address")
+	}
+
+	host := ns.Address.Address.String()
+	if ns.Address.Address.Family().IsIP() {
+		// net.Address.String() brackets IPv6; TLS server names and URLs need it bare here.
+		host = ns.Address.Address.IP().String()
+	}
+	target := endpointTarget(ns.Address)
+	dial := dialerForNameServer(ns)
+
+	switch ns.Transport {
+	case NameServer_UDP:
+		return NewClassicClient("udp", target, dial), nil
+	case NameServer_TCP:
+		return NewClassicClient("tcp", target, dial), nil
+	case NameServer_DOH:
+		return NewDoHClient(dohURL(host, ns), func(ctx context.Context, network, _ string) (stdnet.Conn, error) {
+			return dial(ctx, network, target)
+		}), nil
+	case NameServer_DOT:
+		return NewDoTClient(host, func(ctx context.Context) (stdnet.Conn, error) {
+			return dial(ctx, "tcp", target)
+		}), nil
+	case NameServer_DOQ:
+		return NewDoQClient(host, func(ctx context.Context, tlsConfig *tls.Config) (quic.Connection, error) {
+			pc, remote, err := dialQUICPacketConn(ctx, dial, target)
+			if err != nil {
+				return nil, err
+			}
+			return quic.Dial(ctx, pc, remote, tlsConfig, nil)
+		}), nil
+	default:
+		return nil, newError("unsupported nameserver transport: ", ns.Transport)
+	}
+}
+
+// dialQUICPacketConn dials target through dial and adapts the resulting
+// stream-style net.Conn into the net.PacketConn quic-go needs, so DoQ
+// honors the same Tag/Local routing as every other transport instead of
+// always opening its own UDP socket directly via quic.DialAddr.
+func dialQUICPacketConn(ctx context.Context, dial Dialer, target string) (stdnet.PacketConn, stdnet.Addr, error) {
+	conn, err := dial(ctx, "udp", target)
+	if err != nil {
+		return nil, nil, newError("failed to dial DoQ transport: ", target).Base(err)
+	}
+	// Read the remote address back off conn instead of re-resolving target:
+	// for a domain-named server, a second independent stdnet.ResolveUDPAddr
+	// would do its own unrouted system lookup, defeating the whole point of
+	// dialing target through dial in the first place.
+	remote := conn.RemoteAddr()
+	return &quicPacketConn{Conn: conn, remote: remote}, remote, nil
+}
+
+// quicPacketConn wraps a connected stream-style net.Conn (as returned by a
+// Dialer) as a net.PacketConn: every read and write goes to the single
+// remote address the Conn is already connected to, which is all quic-go
+// needs to drive a QUIC handshake and session over it.
+type quicPacketConn struct {
+	stdnet.Conn
+	remote stdnet.Addr
+}
+
+func (p *quicPacketConn) ReadFrom(b []byte) (int, stdnet.Addr, error) {
+	n, err := p.Conn.Read(b)
+	return n, p.remote, err
+}
+
+func (p *quicPacketConn) WriteTo(b []byte, _ stdnet.Addr) (int, error) {
+	return p.Conn.Write(b)
+}
+
+// dohURL rebuilds the request URL a NameServerConfig's "https://host:port/path"
+// form was parsed from: scheme + host (the port is only written out when it
+// differs from the standard HTTPS port) + path.
+func dohURL(host string, ns *NameServer) string {
+	url := "https://" + bracketIPv6(host)
+	if ns.Address.Port != 443 {
+		url = fmt.Sprintf("%s:%d", url, ns.Address.Port)
+	}
+	return url + ns.Path
+}
+
+// query tries each client in clients in order, returning the first non-empty
+// result. It keeps going past a server that fails or returns no answer, and
+// returns the last error if no server answered. It does not look at
+// skipFallback; LookupIP applies that to the authoritative servers before
+// calling it.
+func (s *Server) query(ctx context.Context, clients []*nameServerClient, domain string) ([]net.Address, error) {
+	var lastErr error
+	for _, c := range clients {
+		ips, err := c.queryIP(ctx, domain, s.strategyFor(c))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(ips) > 0 {
+			return ips, nil
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, newError("no nameserver returned a result for: ", domain)
+}
@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"context"
+	stdnet "net"
+	"testing"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+func TestDialerForNameServerUsesTagHook(t *testing.T) {
+	called := false
+	DialerForTag = func(tag string) Dialer {
+		if tag != "proxy-out" {
+			t.Fatalf("expected tag %q, got %q", "proxy-out", tag)
+		}
+		return func(ctx context.Context, network, addr string) (stdnet.Conn, error) {
+			called = true
+			return nil, newError("stub dialer, not actually dialing")
+		}
+	}
+	defer func() { DialerForTag = nil }()
+
+	dial := dialerForNameServer(&NameServer{Tag: "proxy-out"})
+	if _, err := dial(context.Background(), "udp", "1.2.3.4:53"); err == nil {
+		t.Fatalf("expected the stub dialer's error")
+	}
+	if !called {
+		t.Fatalf("expected DialerForTag's dialer to be used for a tagged nameserver")
+	}
+}
+
+func TestDialerForNameServerLocalIgnoresTag(t *testing.T) {
+	DialerForTag = func(tag string) Dialer {
+		t.Fatalf("DialerForTag should not be consulted for a Local nameserver")
+		return nil
+	}
+	defer func() { DialerForTag = nil }()
+
+	dial := dialerForNameServer(&NameServer{Tag: "proxy-out", Local: true})
+	if _, err := dial(context.Background(), "tcp", "127.0.0.1:1"); err == nil {
+		t.Fatalf("expected defaultDialer's dial attempt to fail against a closed port")
+	}
+}
+
+func TestBuildTransportClientDOQHonorsTag(t *testing.T) {
+	called := false
+	DialerForTag = func(tag string) Dialer {
+		if tag != "proxy-out" {
+			t.Fatalf("expected tag %q, got %q", "proxy-out", tag)
+		}
+		return func(ctx context.Context, network, addr string) (stdnet.Conn, error) {
+			called = true
+			return nil, newError("stub dialer, not actually dialing")
+		}
+	}
+	defer func() { DialerForTag = nil }()
+
+	client, err := buildTransportClient(&NameServer{
+		Address: &net.Endpoint{
+			Network: net.Network_UDP,
+			Address: net.ParseAddress("127.0.0.1"),
+			Port:    853,
+		},
+		Transport: NameServer_DOQ,
+		Tag:       "proxy-out",
+	})
+	if err != nil {
+		t.Fatalf("buildTransportClient: %v", err)
+	}
+
+	// If NameServer_DOQ dialed via quic.DialAddr directly instead of the
+	// resolved Dialer, DialerForTag's dialer would never run and this query
+	// would fail with a dial timeout instead of the stub dialer's error.
+	if _, err := client.QueryRaw(context.Background(), []byte("query")); err == nil {
+		t.Fatalf("expected the stub dialer's failure to surface")
+	}
+	if !called {
+		t.Fatalf("expected DoQ to dial through the Tag's resolved Dialer instead of quic.DialAddr")
+	}
+}
+
+func TestDialerForNameServerFallsBackWithoutTag(t *testing.T) {
+	DialerForTag = func(tag string) Dialer {
+		t.Fatalf("DialerForTag should not be consulted for an untagged nameserver")
+		return nil
+	}
+	defer func() { DialerForTag = nil }()
+
+	dial := dialerForNameServer(&NameServer{})
+	if dial == nil {
+		t.Fatalf("expected a non-nil fallback dialer")
+	}
+}
@@ -0,0 +1,170 @@
+package dns
+
+import (
+	"github.com/xtls/xray-core/app/fakedns"
+	dm "github.com/xtls/xray-core/common/matcher/domain"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// buildFakeDNSPools constructs a fakedns.HolderMulti from the FakeDnsPool
+// descriptors attached to a NameServer or the top-level Config, registering
+// one pool per entry.
+func buildFakeDNSPools(pools []*FakeDnsPool) (*fakedns.HolderMulti, error) {
+	multi := fakedns.NewHolderMulti()
+	for _, pool := range pools {
+		if _, err := multi.Add(pool.IpPool, uint32(pool.LruSize)); err != nil {
+			return nil, newError("failed to create fakedns pool: ", pool.IpPool).Base(err)
+		}
+	}
+	return multi, nil
+}
+
+// FakeDNSEngine allocates synthetic IPs for queried domains, selecting the
+// pool to allocate from by matching the domain against each NameServer's own
+// PrioritizedDomain rules, then using the first ExpectIPs-only NameServer's
+// pools, and falling back to a default pool otherwise. ExpectIPs rules
+// themselves are not matched against anything. It also answers the reverse lookup (fake IP -> original domain)
+// that the sniffing.destOverride: ["fakedns"] code path needs before dialing.
+type FakeDNSEngine struct {
+	perServer []*fakeDNSRoute
+	expectIP  []*fakeDNSRoute
+	def       *fakedns.HolderMulti
+}
+
+type fakeDNSRoute struct {
+	matched *StaticHosts // reused purely for its domain matcher; nil for an ExpectIPs-only route
+	pools   *fakedns.HolderMulti
+}
+
+// NewFakeDNSEngine builds a FakeDNSEngine from a dns.Config: one route per
+// NameServer that declares its own FakeDns pools plus either PrioritizedDomain
+// or ExpectIPs rules, and the top-level FakeDns block as the default pool for
+// anything that doesn't match a per-server route.
+func NewFakeDNSEngine(config *Config) (*FakeDNSEngine, error) {
+	engine := &FakeDNSEngine{}
+
+	for _, ns := range config.NameServer {
+		if len(ns.FakeDns) == 0 {
+			continue
+		}
+		if len(ns.PrioritizedDomain) == 0 && len(ns.Geoip) == 0 {
+			return nil, newError("nameserver fakedns pool requires at least one domain or expectIps rule: ", ns.Address)
+		}
+
+		pools, err := buildFakeDNSPools(ns.FakeDns)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ns.PrioritizedDomain) > 0 {
+			matcher, err := NewStaticHosts(domainRulesToHostMappings(ns.PrioritizedDomain))
+			if err != nil {
+				return nil, newError("failed to build fakedns domain matcher").Base(err)
+			}
+			engine.perServer = append(engine.perServer, &fakeDNSRoute{matched: matcher, pools: pools})
+			continue
+		}
+
+		// An ExpectIPs-only nameserver has nothing to match a domain against
+		// before it's even resolved, so it acts as a second-tier default:
+		// tried after every domain-matched route, but ahead of the top-level
+		// default pool, letting operators give the ExpectIPs-validated
+		// resolver its own fake IP range distinct from the global fallback.
+		engine.expectIP = append(engine.expectIP, &fakeDNSRoute{pools: pools})
+	}
+
+	if len(config.FakeDns) > 0 {
+		def, err := buildFakeDNSPools(config.FakeDns)
+		if err != nil {
+			return nil, err
+		}
+		engine.def = def
+	}
+
+	return engine, nil
+}
+
+// domainRulesToHostMappings adapts a NameServer's PrioritizedDomain rules
+// (dm.Domain) into the Config_HostMapping shape NewStaticHosts expects, so
+// the same domain matcher implementation can be reused for fakedns routing.
+func domainRulesToHostMappings(rules []*dm.Domain) []*Config_HostMapping {
+	mappings := make([]*Config_HostMapping, 0, len(rules))
+	for _, rule := range rules {
+		mappings = append(mappings, &Config_HostMapping{
+			Type:   rule.Type,
+			Domain: rule.Value,
+		})
+	}
+	return mappings
+}
+
+// GetFakeIPForDomain allocates (or returns the existing) fake IP for domain,
+// preferring the first matching per-server (PrioritizedDomain) pool, then
+// the first ExpectIPs-only pool, then the default pool if nothing else
+// matches.
+func (e *FakeDNSEngine) GetFakeIPForDomain(domain string) []net.Address {
+	for _, route := range e.perServer {
+		if !matchesDomain(route.matched, domain) {
+			continue
+		}
+		// Every pool on the matched nameserver allocates independently, so a
+		// v4 pool and a v6 pool declared side by side both contribute an
+		// address for the same domain.
+		return route.pools.GetFakeIPForDomain(domain)
+	}
+	if len(e.expectIP) > 0 {
+		return e.expectIP[0].pools.GetFakeIPForDomain(domain)
+	}
+	if e.def != nil {
+		return e.def.GetFakeIPForDomain(domain)
+	}
+	return nil
+}
+
+// matchesDomain reports whether domain matches hosts' matcher at all,
+// independent of whether any IP mapping was attached to the rule (fakedns
+// domain rules carry no IPs, only match type/value).
+func matchesDomain(hosts *StaticHosts, domain string) bool {
+	return len(hosts.matcher.Match(domain)) > 0
+}
+
+// GetDomainFromFakeDNS resolves ip back to the domain it was allocated for,
+// checking every per-server pool, then every ExpectIPs-only pool, before the
+// default one.
+func (e *FakeDNSEngine) GetDomainFromFakeDNS(ip net.Address) string {
+	for _, route := range e.perServer {
+		if domain := route.pools.GetDomainFromFakeDNS(ip); domain != "" {
+			return domain
+		}
+	}
+	for _, route := range e.expectIP {
+		if domain := route.pools.GetDomainFromFakeDNS(ip); domain != "" {
+			return domain
+		}
+	}
+	if e.def != nil {
+		return e.def.GetDomainFromFakeDNS(ip)
+	}
+	return ""
+}
+
+// ActiveFakeDNSEngine is the FakeDNSEngine of the most recently constructed
+// Server, set even when that Server has no fakedns pools configured (its
+// engine then knows no fake IPs). It exists purely as the integration
+// seam for a per-inbound "sniffing.destOverride": ["fakedns"] code path:
+// that code sniffs a fake IP off the connection before any DNS app lookup is
+// reachable through routing, so it needs a package-level way to resolve that
+// IP back to the real hostname before dialing, the same way DialerForTag is
+// the seam an embedding app's routing engine hooks into.
+var ActiveFakeDNSEngine *FakeDNSEngine
+
+// ResolveFakeIP reports the domain ActiveFakeDNSEngine allocated ip for, and
+// true, or ("", false) if ip isn't a known fake address (including when no
+// fakedns pools are configured at all).
+func ResolveFakeIP(ip net.Address) (string, bool) {
+	if ActiveFakeDNSEngine == nil {
+		return "", false
+	}
+	domain := ActiveFakeDNSEngine.GetDomainFromFakeDNS(ip)
+	return domain, domain != ""
+}
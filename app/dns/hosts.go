@@ -0,0 +1,109 @@
+package dns
+
+import (
+	dm "github.com/xtls/xray-core/common/matcher/domain"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/strmatcher"
+)
+
+// StaticHosts resolves domains to IPs or a proxied domain using the static
+// host mappings declared in the DNS config.
+type StaticHosts struct {
+	ips     [][]net.Address
+	domains []string
+	matcher *strmatcher.MatcherGroup
+}
+
+// toStrMatcherType converts the domain matching type carried by a
+// Config_HostMapping (as produced by infra/conf) into the strmatcher.Type
+// used to build the lookup matcher.
+func toStrMatcherType(t dm.MatchingType) (strmatcher.Type, error) {
+	switch t {
+	case dm.MatchingType_Full:
+		return strmatcher.Full, nil
+	case dm.MatchingType_Subdomain:
+		return strmatcher.Domain, nil
+	case dm.MatchingType_Keyword:
+		return strmatcher.Substr, nil
+	case dm.MatchingType_Regex:
+		return strmatcher.Regex, nil
+	default:
+		return 0, newError("unsupported matching type: ", t)
+	}
+}
+
+// NewStaticHosts creates a new StaticHosts from a list of host mappings.
+func NewStaticHosts(hosts []*Config_HostMapping) (*StaticHosts, error) {
+	g := new(strmatcher.MatcherGroup)
+	sh := &StaticHosts{
+		ips:     make([][]net.Address, len(hosts)),
+		domains: make([]string, len(hosts)),
+	}
+
+	for idx, mapping := range hosts {
+		matcherType, err := toStrMatcherType(mapping.Type)
+		if err != nil {
+			return nil, newError("unknown mapping type: ", mapping.Type).Base(err)
+		}
+		am, err := matcherType.New(mapping.Domain)
+		if err != nil {
+			return nil, newError("invalid domain rule: ", mapping.Domain).Base(err)
+		}
+		id := g.Add(am)
+
+		var ips []net.Address
+		for _, ip := range mapping.Ip {
+			ips = append(ips, net.IPAddress(ip))
+		}
+		sh.ips[id-1] = ips
+		sh.domains[id-1] = mapping.ProxiedDomain
+	}
+
+	sh.matcher = g
+	return sh, nil
+}
+
+func filterIP(ips []net.Address, strategy QueryStrategy) []net.Address {
+	if strategy == QueryStrategy_USE_IP {
+		return ips
+	}
+
+	filtered := make([]net.Address, 0, len(ips))
+	for _, ip := range ips {
+		switch strategy {
+		case QueryStrategy_USE_IP4:
+			if ip.Family().IsIPv4() {
+				filtered = append(filtered, ip)
+			}
+		case QueryStrategy_USE_IP6:
+			if ip.Family().IsIPv6() {
+				filtered = append(filtered, ip)
+			}
+		}
+	}
+	return filtered
+}
+
+// LookupIP returns the IPs, if any, and the proxied domain, if any, matching
+// the given domain under the given query strategy. When a mapping carries
+// both IPs and a proxied domain, callers should prefer the IPs and only fall
+// back to the domain when no IP survives the strategy's filter.
+func (h *StaticHosts) LookupIP(domain string, strategy QueryStrategy) ([]net.Address, string) {
+	ids := h.matcher.Match(domain)
+	if len(ids) == 0 {
+		return nil, ""
+	}
+
+	var ips []net.Address
+	var proxied string
+	for _, id := range ids {
+		idx := id - 1
+		if filtered := filterIP(h.ips[idx], strategy); len(filtered) > 0 {
+			ips = append(ips, filtered...)
+		}
+		if h.domains[idx] != "" {
+			proxied = h.domains[idx]
+		}
+	}
+	return ips, proxied
+}
@@ -0,0 +1,41 @@
+package dns
+
+import (
+	"context"
+	stdnet "net"
+	"time"
+)
+
+// Client is implemented by each transport-specific nameserver client
+// (classic UDP/TCP, DoH, DoT, DoQ) so the DNS app can treat them uniformly
+// regardless of how the query is actually carried.
+type Client interface {
+	// Name returns a human readable identifier for this client, used in logs.
+	Name() string
+
+	// QueryRaw sends a raw (wire-format) DNS message to the server and
+	// returns the raw response.
+	QueryRaw(ctx context.Context, msg []byte) ([]byte, error)
+}
+
+// Dialer establishes the underlying connection a nameserver client sends its
+// queries over. It exists so a NameServer's Local flag can be honored: a
+// local server dials directly, while a routed one would hand this same
+// signature to the proxy engine instead of defaultDialer.
+type Dialer func(ctx context.Context, network, addr string) (stdnet.Conn, error)
+
+// defaultDialer dials directly with the standard library, bypassing any
+// routing engine. It is what every Local nameserver uses, and what any
+// other nameserver falls back to when it has no Tag, or DialerForTag isn't
+// set, or returns nil for that tag.
+var defaultDialer Dialer = func(ctx context.Context, network, addr string) (stdnet.Conn, error) {
+	d := stdnet.Dialer{Timeout: time.Second * 10}
+	return d.DialContext(ctx, network, addr)
+}
+
+// DialerForTag resolves a NameServer's Tag to the Dialer that should carry
+// its queries. This is the seam an embedding application hooks its routing
+// engine into: set it before constructing a Server to send a tagged
+// nameserver's traffic through a specific outbound instead of dialing
+// directly. Left nil (the default), every nameserver dials directly.
+var DialerForTag func(tag string) Dialer
@@ -0,0 +1,55 @@
+package dns
+
+import (
+	"context"
+	"time"
+)
+
+// ClassicClient queries a nameserver using plain RFC 1035 DNS: a single
+// datagram over UDP, or the 2-byte length-prefixed stream format over TCP.
+type ClassicClient struct {
+	network string // "udp" or "tcp"
+	target  string
+	dial    Dialer
+}
+
+// NewClassicClient creates a ClassicClient that dials target (a "host:port"
+// string) over network ("udp" or "tcp") using dial.
+func NewClassicClient(network, target string, dial Dialer) *ClassicClient {
+	return &ClassicClient{network: network, target: target, dial: dial}
+}
+
+func (c *ClassicClient) Name() string {
+	return c.network + ":" + c.target
+}
+
+func (c *ClassicClient) QueryRaw(ctx context.Context, msg []byte) ([]byte, error) {
+	conn, err := c.dial(ctx, c.network, c.target)
+	if err != nil {
+		return nil, newError("failed to dial nameserver: ", c.target).Base(err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(time.Second * 10))
+	}
+
+	if c.network == "tcp" {
+		if err := writeDNSOverStream(conn, msg); err != nil {
+			return nil, newError("failed to send query: ", c.target).Base(err)
+		}
+		return readDNSOverStream(conn)
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return nil, newError("failed to send query: ", c.target).Base(err)
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, newError("failed to read response: ", c.target).Base(err)
+	}
+	return buf[:n], nil
+}
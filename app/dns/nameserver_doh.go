@@ -0,0 +1,68 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"io"
+	stdnet "net"
+	"net/http"
+	"time"
+)
+
+const dohContentType = "application/dns-message"
+
+// DoHClient queries a DNS-over-HTTPS server using RFC 8484's wire format,
+// reusing a single *http.Client (and its connection pool) across queries.
+type DoHClient struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewDoHClient creates a DoHClient for the given server URL, e.g.
+// "https://dns.google/dns-query". dial, when non-nil, is used in place of
+// the default transport's dialer so callers can route the underlying TCP
+// connection through xray's own dialing stack instead of the OS resolver.
+func NewDoHClient(url string, dial func(ctx context.Context, network, addr string) (stdnet.Conn, error)) *DoHClient {
+	transport := &http.Transport{
+		IdleConnTimeout:   90 * time.Second,
+		ForceAttemptHTTP2: true,
+	}
+	if dial != nil {
+		transport.DialContext = dial
+	}
+
+	return &DoHClient{
+		url: url,
+		httpClient: &http.Client{
+			Timeout:   time.Second * 10,
+			Transport: transport,
+		},
+	}
+}
+
+func (c *DoHClient) Name() string {
+	return "DoH:" + c.url
+}
+
+// QueryRaw POSTs msg as the request body, per RFC 8484, and returns the
+// response body unmodified.
+func (c *DoHClient) QueryRaw(ctx context.Context, msg []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, newError("failed to create DoH request").Base(err)
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, newError("failed to dial DoH server: ", c.url).Base(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newError("DoH server returned status ", resp.StatusCode, " for ", c.url)
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,72 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	stdnet "net"
+	"time"
+)
+
+// DoTClient queries a DNS-over-TLS server (RFC 7858): classic DNS wire
+// format over a length-prefixed TCP stream wrapped in TLS.
+type DoTClient struct {
+	serverName string
+	dial       func(ctx context.Context) (stdnet.Conn, error)
+}
+
+// NewDoTClient creates a DoTClient for serverName (used for both the TLS SNI
+// and certificate verification). dial establishes the underlying TCP
+// connection, letting callers route it through xray's own dialer.
+func NewDoTClient(serverName string, dial func(ctx context.Context) (stdnet.Conn, error)) *DoTClient {
+	return &DoTClient{serverName: serverName, dial: dial}
+}
+
+func (c *DoTClient) Name() string {
+	return "DoT:" + c.serverName
+}
+
+func (c *DoTClient) QueryRaw(ctx context.Context, msg []byte) ([]byte, error) {
+	rawConn, err := c.dial(ctx)
+	if err != nil {
+		return nil, newError("failed to dial DoT server: ", c.serverName).Base(err)
+	}
+	defer rawConn.Close()
+
+	conn := tls.Client(rawConn, &tls.Config{ServerName: c.serverName})
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(time.Second * 10))
+	}
+
+	if err := writeDNSOverStream(conn, msg); err != nil {
+		return nil, newError("failed to send DoT query: ", c.serverName).Base(err)
+	}
+	return readDNSOverStream(conn)
+}
+
+// writeDNSOverStream and readDNSOverStream implement the 2-byte big-endian
+// length prefix that DoT, DoQ and classic TCP DNS all use (RFC 1035 §4.2.2,
+// reused by RFC 9250 for DoQ).
+func writeDNSOverStream(stream io.Writer, msg []byte) error {
+	prefix := []byte{byte(len(msg) >> 8), byte(len(msg))}
+	if _, err := stream.Write(prefix); err != nil {
+		return err
+	}
+	_, err := stream.Write(msg)
+	return err
+}
+
+func readDNSOverStream(stream io.Reader) ([]byte, error) {
+	var prefix [2]byte
+	if _, err := io.ReadFull(stream, prefix[:]); err != nil {
+		return nil, err
+	}
+	size := int(prefix[0])<<8 | int(prefix[1])
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,67 @@
+package dns
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// queryIP sends an A and/or AAAA query for domain to c's transport,
+// depending on strategy, and collects the resulting addresses.
+func (c *nameServerClient) queryIP(ctx context.Context, domain string, strategy QueryStrategy) ([]net.Address, error) {
+	var qTypes []uint16
+	switch strategy {
+	case QueryStrategy_USE_IP4:
+		qTypes = []uint16{dns.TypeA}
+	case QueryStrategy_USE_IP6:
+		qTypes = []uint16{dns.TypeAAAA}
+	default:
+		qTypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	}
+
+	var ips []net.Address
+	for _, qType := range qTypes {
+		answer, err := c.queryRaw(ctx, domain, qType)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, answer...)
+	}
+	return ips, nil
+}
+
+// queryRaw packs a single-question DNS query for (domain, qType), sends it
+// over c's transport, and extracts the A/AAAA records from the response.
+func (c *nameServerClient) queryRaw(ctx context.Context, domain string, qType uint16) ([]net.Address, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(domain), qType)
+	msg.RecursionDesired = true
+
+	rawQuery, err := msg.Pack()
+	if err != nil {
+		return nil, newError("failed to pack DNS query for: ", domain).Base(err)
+	}
+
+	rawResponse, err := c.transport.QueryRaw(ctx, rawQuery)
+	if err != nil {
+		return nil, newError("nameserver query failed for: ", domain, " via ", c.transport.Name()).Base(err)
+	}
+
+	response := new(dns.Msg)
+	if err := response.Unpack(rawResponse); err != nil {
+		return nil, newError("failed to parse DNS response for: ", domain, " from ", c.transport.Name()).Base(err)
+	}
+
+	var ips []net.Address
+	for _, rr := range response.Answer {
+		switch record := rr.(type) {
+		case *dns.A:
+			ips = append(ips, net.IPAddress(record.A))
+		case *dns.AAAA:
+			ips = append(ips, net.IPAddress(record.AAAA))
+		}
+	}
+	return ips, nil
+}
@@ -0,0 +1,87 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// dnsOverQUICALPN is the ALPN token for DNS-over-QUIC, registered in RFC 9250.
+var dnsOverQUICALPN = []string{"doq"}
+
+// DoQClient queries a DNS-over-QUIC server (RFC 9250): each query gets its
+// own bidirectional stream on a shared QUIC connection.
+type DoQClient struct {
+	serverName string
+	dial       func(ctx context.Context, tlsConfig *tls.Config) (quic.Connection, error)
+
+	sync.Mutex
+	connection quic.Connection
+}
+
+// NewDoQClient creates a DoQClient for serverName. dial establishes the
+// underlying QUIC connection, letting callers route it through xray's own
+// dialer rather than net.Dial directly.
+func NewDoQClient(serverName string, dial func(ctx context.Context, tlsConfig *tls.Config) (quic.Connection, error)) *DoQClient {
+	return &DoQClient{serverName: serverName, dial: dial}
+}
+
+func (c *DoQClient) Name() string {
+	return "DoQ:" + c.serverName
+}
+
+func (c *DoQClient) getConnection(ctx context.Context) (quic.Connection, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.connection != nil {
+		select {
+		case <-c.connection.Context().Done():
+			// Previous connection died; fall through and redial.
+		default:
+			return c.connection, nil
+		}
+	}
+
+	conn, err := c.dial(ctx, &tls.Config{ServerName: c.serverName, NextProtos: dnsOverQUICALPN})
+	if err != nil {
+		return nil, newError("failed to dial DoQ server: ", c.serverName).Base(err)
+	}
+	c.connection = conn
+	return conn, nil
+}
+
+func (c *DoQClient) QueryRaw(ctx context.Context, msg []byte) ([]byte, error) {
+	conn, err := c.getConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, newError("failed to open DoQ stream: ", c.serverName).Base(err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	} else {
+		_ = stream.SetDeadline(time.Now().Add(time.Second * 10))
+	}
+
+	// DoQ reuses the 2-byte length prefix from classic DNS-over-TCP.
+	if err := writeDNSOverStream(stream, msg); err != nil {
+		stream.Close()
+		return nil, newError("failed to send DoQ query: ", c.serverName).Base(err)
+	}
+	// RFC 9250 has the client half-close its side of the stream right after
+	// sending the query, so the server sees the FIN and knows no more data
+	// is coming. Servers that wait for a clean half-close before answering
+	// (e.g. dns.adguard.com) would otherwise stall until the deadline above.
+	if err := stream.Close(); err != nil {
+		return nil, newError("failed to close DoQ send stream: ", c.serverName).Base(err)
+	}
+	return readDNSOverStream(stream)
+}
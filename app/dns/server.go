@@ -0,0 +1,127 @@
+package dns
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// Server resolves domains using a Config's static hosts, fakedns pools and
+// nameservers, in that order. Among the nameservers, one that claims a
+// domain via its own PrioritizedDomain rules is treated as authoritative
+// for it: only that server (or servers) is queried, and every authoritative
+// server is still given a chance even after one of them fails. Only once
+// all of them have failed does a SkipFallback among them stop the query
+// from falling through to the general-purpose servers.
+type Server struct {
+	clients       []*nameServerClient
+	hosts         *StaticHosts
+	fakeDNS       *FakeDNSEngine
+	queryStrategy QueryStrategy
+}
+
+// NewServer builds a Server from a fully parsed dns.Config.
+func NewServer(config *Config) (*Server, error) {
+	s := &Server{queryStrategy: config.QueryStrategy}
+
+	if len(config.StaticHosts) > 0 {
+		hosts, err := NewStaticHosts(config.StaticHosts)
+		if err != nil {
+			return nil, newError("failed to build static hosts").Base(err)
+		}
+		s.hosts = hosts
+	}
+
+	fakeDNS, err := NewFakeDNSEngine(config)
+	if err != nil {
+		return nil, err
+	}
+	s.fakeDNS = fakeDNS
+	ActiveFakeDNSEngine = fakeDNS
+
+	for _, ns := range config.NameServer {
+		client, err := newNameServerClient(ns)
+		if err != nil {
+			return nil, err
+		}
+		s.clients = append(s.clients, client)
+	}
+
+	return s, nil
+}
+
+// LookupIP resolves domain to a list of IPs, consulting static hosts first.
+// A static host entry that maps to a proxied domain instead of (or in
+// addition to) IPs causes the lookup to continue against that domain.
+func (s *Server) LookupIP(ctx context.Context, domain string) ([]net.Address, error) {
+	if s.hosts != nil {
+		ips, proxied := s.hosts.LookupIP(domain, s.queryStrategy)
+		if len(ips) > 0 {
+			return ips, nil
+		}
+		if proxied != "" {
+			domain = proxied
+		}
+	}
+
+	if s.fakeDNS != nil {
+		if ips := s.fakeDNS.GetFakeIPForDomain(domain); len(ips) > 0 {
+			return ips, nil
+		}
+	}
+
+	var authoritative, general []*nameServerClient
+	for _, c := range s.clients {
+		if c.handles(domain) {
+			authoritative = append(authoritative, c)
+		} else {
+			general = append(general, c)
+		}
+	}
+
+	var skipFallbackErr error
+	for _, c := range authoritative {
+		ips, err := c.queryIP(ctx, domain, s.strategyFor(c))
+		if err != nil {
+			if c.skipFallback {
+				skipFallbackErr = err
+			}
+			continue
+		}
+		if len(ips) > 0 {
+			return ips, nil
+		}
+	}
+
+	// Every authoritative server got a chance above; only give up on the
+	// general-purpose servers if one of the authoritative failures asked us
+	// to (SkipFallback), and none of the others picked up the slack.
+	if skipFallbackErr != nil {
+		return nil, newError("authoritative nameserver failed for: ", domain, ", not falling back").Base(skipFallbackErr)
+	}
+
+	return s.query(ctx, general, domain)
+}
+
+// GetFakeIPForDomain and GetDomainFromFakeDNS delegate to the Server's
+// FakeDNSEngine, giving callers (e.g. sniffing.destOverride: ["fakedns"])
+// a single entry point for both the Server and its fake DNS pools.
+func (s *Server) GetFakeIPForDomain(domain string) []net.Address {
+	return s.fakeDNS.GetFakeIPForDomain(domain)
+}
+
+func (s *Server) GetDomainFromFakeDNS(ip net.Address) string {
+	return s.fakeDNS.GetDomainFromFakeDNS(ip)
+}
+
+// strategyFor returns c's own QueryStrategy override, falling back to the
+// Server's config-level default when c leaves it QueryStrategy_UNSPECIFIED.
+// UNSPECIFIED is a dedicated zero value distinct from USE_IP, so a server
+// that explicitly opts into USE_IP is never confused with one that never set
+// a strategy at all.
+func (s *Server) strategyFor(c *nameServerClient) QueryStrategy {
+	if c != nil && c.queryStrategy != QueryStrategy_UNSPECIFIED {
+		return c.queryStrategy
+	}
+	return s.queryStrategy
+}
@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	dm "github.com/xtls/xray-core/common/matcher/domain"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// fakeTransport is a Client that answers with a fixed IP, or fails, without
+// touching the network.
+type fakeTransport struct {
+	name string
+	ip   net.IP
+	fail bool
+}
+
+func (f *fakeTransport) Name() string { return f.name }
+
+func (f *fakeTransport) QueryRaw(ctx context.Context, msg []byte) ([]byte, error) {
+	if f.fail {
+		return nil, newError("simulated failure: ", f.name)
+	}
+
+	req := new(dns.Msg)
+	if err := req.Unpack(msg); err != nil {
+		return nil, err
+	}
+
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Answer = append(resp.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   f.ip,
+	})
+	return resp.Pack()
+}
+
+// authoritativeFor builds the StaticHosts-backed matcher a nameServerClient
+// uses to claim a single exact domain, mirroring what PrioritizedDomain
+// rules would produce.
+func authoritativeFor(t *testing.T, domain string) *StaticHosts {
+	t.Helper()
+	matcher, err := NewStaticHosts([]*Config_HostMapping{{Type: dm.MatchingType_Full, Domain: domain}})
+	if err != nil {
+		t.Fatalf("NewStaticHosts: %v", err)
+	}
+	return matcher
+}
+
+func TestServerPrefersAuthoritativeNameServer(t *testing.T) {
+	authoritative := newTestClient(authoritativeFor(t, "corp.example.com"), false, &fakeTransport{name: "authoritative", ip: net.IP{10, 0, 0, 1}})
+	general := newTestClient(nil, false, &fakeTransport{name: "general", ip: net.IP{8, 8, 8, 8}})
+
+	s := &Server{clients: []*nameServerClient{authoritative, general}, queryStrategy: QueryStrategy_USE_IP4}
+
+	ips, err := s.LookupIP(context.Background(), "corp.example.com")
+	if err != nil {
+		t.Fatalf("LookupIP: %v", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "10.0.0.1" {
+		t.Fatalf("expected the authoritative server's IP, got %v", ips)
+	}
+}
+
+func TestServerSkipFallbackStopsOnAuthoritativeFailure(t *testing.T) {
+	authoritative := newTestClient(authoritativeFor(t, "corp.example.com"), true, &fakeTransport{name: "authoritative", fail: true})
+	general := newTestClient(nil, false, &fakeTransport{name: "general", ip: net.IP{8, 8, 8, 8}})
+
+	s := &Server{clients: []*nameServerClient{authoritative, general}, queryStrategy: QueryStrategy_USE_IP4}
+
+	if _, err := s.LookupIP(context.Background(), "corp.example.com"); err == nil {
+		t.Fatalf("expected skipFallback to surface the authoritative server's error, got nil")
+	}
+}
+
+func TestServerTriesEveryAuthoritativeNameServerBeforeGivingUp(t *testing.T) {
+	failing := newTestClient(authoritativeFor(t, "corp.example.com"), true, &fakeTransport{name: "failing-authoritative", fail: true})
+	backup := newTestClient(authoritativeFor(t, "corp.example.com"), true, &fakeTransport{name: "backup-authoritative", ip: net.IP{10, 0, 0, 2}})
+	general := newTestClient(nil, false, &fakeTransport{name: "general", ip: net.IP{8, 8, 8, 8}})
+
+	s := &Server{clients: []*nameServerClient{failing, backup, general}, queryStrategy: QueryStrategy_USE_IP4}
+
+	ips, err := s.LookupIP(context.Background(), "corp.example.com")
+	if err != nil {
+		t.Fatalf("LookupIP: %v", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "10.0.0.2" {
+		t.Fatalf("expected the second authoritative server's IP after the first failed, got %v", ips)
+	}
+}
+
+func TestServerFallsBackWhenAuthoritativeFailsWithoutSkipFallback(t *testing.T) {
+	authoritative := newTestClient(authoritativeFor(t, "corp.example.com"), false, &fakeTransport{name: "authoritative", fail: true})
+	general := newTestClient(nil, false, &fakeTransport{name: "general", ip: net.IP{8, 8, 8, 8}})
+
+	s := &Server{clients: []*nameServerClient{authoritative, general}, queryStrategy: QueryStrategy_USE_IP4}
+
+	ips, err := s.LookupIP(context.Background(), "corp.example.com")
+	if err != nil {
+		t.Fatalf("LookupIP: %v", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "8.8.8.8" {
+		t.Fatalf("expected fallback to the general server's IP, got %v", ips)
+	}
+}
+
+func TestStrategyForDistinguishesExplicitUseIPFromUnset(t *testing.T) {
+	s := &Server{queryStrategy: QueryStrategy_USE_IP4}
+
+	unset := &nameServerClient{queryStrategy: QueryStrategy_UNSPECIFIED}
+	if got := s.strategyFor(unset); got != QueryStrategy_USE_IP4 {
+		t.Fatalf("expected an unset client to inherit the server's USE_IP4, got %v", got)
+	}
+
+	explicitUseIP := &nameServerClient{queryStrategy: QueryStrategy_USE_IP}
+	if got := s.strategyFor(explicitUseIP); got != QueryStrategy_USE_IP {
+		t.Fatalf("expected an explicit USE_IP override to stick instead of inheriting USE_IP4, got %v", got)
+	}
+}
+
+// newTestClient builds a nameServerClient directly, bypassing
+// newNameServerClient/buildTransportClient which require a real
+// net.Endpoint, so tests can inject a fakeTransport instead.
+func newTestClient(domains *StaticHosts, skipFallback bool, transport Client) *nameServerClient {
+	return &nameServerClient{queryStrategy: QueryStrategy_USE_IP4, skipFallback: skipFallback, domains: domains, transport: transport}
+}
@@ -0,0 +1,140 @@
+package fakedns
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// hostEntry is the value stored in Holder.lru; el.domain/el.ip let an
+// eviction at the back of the list clean up both index maps in one step.
+type hostEntry struct {
+	domain string
+	ip     net.Address
+}
+
+// Holder hands out synthetic IPs from a single CIDR range and remembers the
+// domain each one was allocated for, so that a later reverse lookup can
+// recover the original hostname. At most capacity domains are tracked at
+// once; the least recently used one is evicted, and its IP reused, once
+// that limit is reached.
+type Holder struct {
+	sync.Mutex
+
+	ipRange  *net.IPNet
+	capacity uint64
+	allocated uint64
+
+	lru        *list.List
+	domainToEl map[string]*list.Element
+	ipToEl     map[string]*list.Element
+}
+
+// NewHolder creates a Holder that allocates fake IPs out of ipPoolCidr, a
+// CIDR such as "198.18.0.0/15", keeping at most lruSize live mappings (or
+// however many addresses the range actually holds, if that is smaller).
+func NewHolder(ipPoolCidr string, lruSize uint32) (*Holder, error) {
+	_, ipRange, err := net.ParseCIDR(ipPoolCidr)
+	if err != nil {
+		return nil, newError("failed to parse fake dns IP pool: ", ipPoolCidr).Base(err)
+	}
+
+	capacity := rangeSize(ipRange)
+	if uint64(lruSize) < capacity {
+		capacity = uint64(lruSize)
+	}
+	if capacity == 0 {
+		return nil, newError("fake dns pool has no usable capacity: ", ipPoolCidr)
+	}
+
+	return &Holder{
+		ipRange:    ipRange,
+		capacity:   capacity,
+		lru:        list.New(),
+		domainToEl: make(map[string]*list.Element, capacity),
+		ipToEl:     make(map[string]*list.Element, capacity),
+	}, nil
+}
+
+// GetFakeIPForDomain returns the fake IP allocated to domain, allocating a
+// fresh one (evicting the least recently used mapping if the pool is full)
+// if this is the first time domain is seen.
+func (h *Holder) GetFakeIPForDomain(domain string) []net.Address {
+	h.Lock()
+	defer h.Unlock()
+
+	if el, found := h.domainToEl[domain]; found {
+		h.lru.MoveToFront(el)
+		return []net.Address{el.Value.(*hostEntry).ip}
+	}
+
+	var ip net.Address
+	if h.allocated < h.capacity {
+		ip = h.nextAddress()
+		h.allocated++
+	} else {
+		back := h.lru.Back()
+		evicted := back.Value.(*hostEntry)
+		delete(h.domainToEl, evicted.domain)
+		delete(h.ipToEl, evicted.ip.String())
+		h.lru.Remove(back)
+		ip = evicted.ip
+	}
+
+	el := h.lru.PushFront(&hostEntry{domain: domain, ip: ip})
+	h.domainToEl[domain] = el
+	h.ipToEl[ip.String()] = el
+	return []net.Address{ip}
+}
+
+// GetDomainFromFakeDNS returns the domain that ip was allocated for, or an
+// empty string if ip is not a fake IP known to this pool.
+func (h *Holder) GetDomainFromFakeDNS(ip net.Address) string {
+	h.Lock()
+	defer h.Unlock()
+
+	el, found := h.ipToEl[ip.String()]
+	if !found {
+		return ""
+	}
+	h.lru.MoveToFront(el)
+	return el.Value.(*hostEntry).domain
+}
+
+// IsIPInIPPool reports whether ip falls within this pool's CIDR range.
+func (h *Holder) IsIPInIPPool(ip net.Address) bool {
+	if !ip.Family().IsIP() {
+		return false
+	}
+	return h.ipRange.Contains(ip.IP())
+}
+
+// nextAddress returns the h.allocated-th address in the pool, counting up
+// from the range's base address. It is only ever called while the pool
+// still has room, i.e. before any eviction has happened.
+func (h *Holder) nextAddress() net.Address {
+	base := h.ipRange.IP
+	ip := make(net.IP, len(base))
+	copy(ip, base)
+
+	offset := h.allocated
+	for i := len(ip) - 1; i >= 0 && offset > 0; i-- {
+		sum := uint64(ip[i]) + offset
+		ip[i] = byte(sum)
+		offset = sum >> 8
+	}
+
+	return net.IPAddress(ip)
+}
+
+// rangeSize returns how many addresses n's mask admits, capped at
+// math.MaxUint64 so an IPv6 /0 or similar doesn't overflow.
+func rangeSize(n *net.IPNet) uint64 {
+	ones, bits := n.Mask.Size()
+	free := bits - ones
+	if free >= 64 {
+		return ^uint64(0)
+	}
+	return uint64(1) << uint(free)
+}
@@ -0,0 +1,58 @@
+package fakedns
+
+import "github.com/xtls/xray-core/common/net"
+
+// HolderMulti owns a set of independent Holder pools, indexed by the order
+// they were added in, so a caller can keep one pool per NameServer.
+type HolderMulti struct {
+	pools []*Holder
+}
+
+// NewHolderMulti creates an empty HolderMulti; pools are added with Add.
+func NewHolderMulti() *HolderMulti {
+	return &HolderMulti{}
+}
+
+// Add registers a new pool and returns its index, used by callers to target
+// GetFakeIPForDomain/GetDomainFromFakeDNS at this specific pool.
+func (m *HolderMulti) Add(ipPoolCidr string, lruSize uint32) (int, error) {
+	holder, err := NewHolder(ipPoolCidr, lruSize)
+	if err != nil {
+		return 0, err
+	}
+	m.pools = append(m.pools, holder)
+	return len(m.pools) - 1, nil
+}
+
+// Pool returns the Holder at idx, as returned by a prior call to Add.
+func (m *HolderMulti) Pool(idx int) *Holder {
+	if idx < 0 || idx >= len(m.pools) {
+		return nil
+	}
+	return m.pools[idx]
+}
+
+// GetFakeIPForDomain allocates (or returns the existing) fake IP for domain
+// from every pool in m, so e.g. a v4 pool and a v6 pool added side by side
+// both hand back an address for the same domain instead of only the first.
+func (m *HolderMulti) GetFakeIPForDomain(domain string) []net.Address {
+	var ips []net.Address
+	for _, pool := range m.pools {
+		ips = append(ips, pool.GetFakeIPForDomain(domain)...)
+	}
+	return ips
+}
+
+// GetDomainFromFakeDNS searches every pool for ip, returning the domain it
+// was allocated for. Pools are disjoint CIDRs in practice, so at most one
+// will ever match.
+func (m *HolderMulti) GetDomainFromFakeDNS(ip net.Address) string {
+	for _, pool := range m.pools {
+		if pool.IsIPInIPPool(ip) {
+			if domain := pool.GetDomainFromFakeDNS(ip); domain != "" {
+				return domain
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package fakedns
+
+import (
+	"testing"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+func TestHolderAllocatesDistinctIPs(t *testing.T) {
+	h, err := NewHolder("198.18.0.0/15", 4)
+	if err != nil {
+		t.Fatalf("NewHolder: %v", err)
+	}
+
+	a := h.GetFakeIPForDomain("a.example.com")[0]
+	b := h.GetFakeIPForDomain("b.example.com")[0]
+	if a.String() == b.String() {
+		t.Fatalf("expected distinct fake IPs for distinct domains, got %s for both", a.String())
+	}
+
+	// Re-querying the same domain must return the same IP.
+	again := h.GetFakeIPForDomain("a.example.com")[0]
+	if again.String() != a.String() {
+		t.Fatalf("expected stable IP for repeated lookup, got %s then %s", a.String(), again.String())
+	}
+}
+
+func TestHolderEvictsLeastRecentlyUsedWhenFull(t *testing.T) {
+	// Capacity is clamped to 4 even though lruSize asks for more, since a
+	// /30 pool only has 4 addresses.
+	h, err := NewHolder("192.0.2.0/30", 100)
+	if err != nil {
+		t.Fatalf("NewHolder: %v", err)
+	}
+
+	domains := []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com"}
+	ips := make(map[string]net.Address, len(domains))
+	for _, d := range domains {
+		ips[d] = h.GetFakeIPForDomain(d)[0]
+	}
+
+	// Touch "a" so it is no longer the least recently used entry.
+	h.GetFakeIPForDomain("a.example.com")
+
+	// Allocating a brand new domain should evict "b", the new least
+	// recently used entry, and reuse its IP.
+	eIP := h.GetFakeIPForDomain("e.example.com")[0]
+	if eIP.String() != ips["b.example.com"].String() {
+		t.Fatalf("expected eviction to reuse b's IP %s, got %s", ips["b.example.com"].String(), eIP.String())
+	}
+	if domain := h.GetDomainFromFakeDNS(eIP); domain != "e.example.com" {
+		t.Fatalf("expected reused IP to resolve back to the new domain, got %q", domain)
+	}
+}
+
+func TestHolderMultiAllocatesFromEveryPool(t *testing.T) {
+	m := NewHolderMulti()
+	if _, err := m.Add("198.18.0.0/24", 4); err != nil {
+		t.Fatalf("Add v4 pool: %v", err)
+	}
+	if _, err := m.Add("fc00::/120", 4); err != nil {
+		t.Fatalf("Add v6 pool: %v", err)
+	}
+
+	ips := m.GetFakeIPForDomain("a.example.com")
+	if len(ips) != 2 {
+		t.Fatalf("expected one fake IP per pool (v4 and v6), got %d: %v", len(ips), ips)
+	}
+	if ips[0].Family().IsIPv6() || !ips[0].Family().IsIPv4() {
+		t.Fatalf("expected the first pool's IP to be IPv4, got %s", ips[0].String())
+	}
+	if !ips[1].Family().IsIPv6() {
+		t.Fatalf("expected the second pool's IP to be IPv6, got %s", ips[1].String())
+	}
+}
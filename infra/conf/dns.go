@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/xtls/xray-core/app/dns"
@@ -15,37 +16,190 @@ import (
 	"github.com/xtls/xray-core/infra/conf/common"
 )
 
+// nameServerSchemes maps a URL-style address scheme, as written in a
+// server's "address", to the transport it selects. The "+local" suffix
+// (e.g. "https+local://") keeps that scheme but dials directly instead of
+// going through the routing engine. Longer schemes are listed first so the
+// "+local" variant is matched before its plain counterpart.
+var nameServerSchemes = []struct {
+	prefix    string
+	transport dns.NameServer_Transport
+	local     bool
+}{
+	{"https+local://", dns.NameServer_DOH, true},
+	{"tls+local://", dns.NameServer_DOT, true},
+	{"quic+local://", dns.NameServer_DOQ, true},
+	{"tcp+local://", dns.NameServer_TCP, true},
+	{"https://", dns.NameServer_DOH, false},
+	{"tls://", dns.NameServer_DOT, false},
+	{"quic://", dns.NameServer_DOQ, false},
+	{"tcp://", dns.NameServer_TCP, false},
+}
+
+func defaultPortForTransport(transport dns.NameServer_Transport) uint16 {
+	switch transport {
+	case dns.NameServer_DOH:
+		return 443
+	case dns.NameServer_DOT, dns.NameServer_DOQ:
+		return 853
+	default:
+		return 53
+	}
+}
+
 type NameServerConfig struct {
-	Address   *common.Address
-	Port      uint16
-	Domains   []string
-	ExpectIPs common.StringList
+	Address       *common.Address
+	Port          uint16
+	Domains       []string
+	ExpectIPs     common.StringList
+	FakeDNS       *FakeDNSConfig
+	Tag           string
+	QueryStrategy string
+	SkipFallback  bool
+	transport     dns.NameServer_Transport
+	local         bool
+	path          string
 }
 
-func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
+// parseAddressString splits a raw "address" value into its transport scheme
+// (if any), host, optional port, and, for DoH, the request path, then
+// resolves the host through the usual common.Address JSON parsing.
+func (c *NameServerConfig) parseAddressString(raw string) error {
+	rest := raw
+	for _, scheme := range nameServerSchemes {
+		if strings.HasPrefix(raw, scheme.prefix) {
+			c.transport = scheme.transport
+			c.local = scheme.local
+			rest = raw[len(scheme.prefix):]
+			break
+		}
+	}
+
+	host := rest
+	if c.transport == dns.NameServer_DOH {
+		if idx := strings.Index(rest, "/"); idx >= 0 {
+			host = rest[:idx]
+			c.path = rest[idx:]
+		}
+	}
+
+	// Only a bracketed "[ipv6]:port" or a "host:port"/"ipv4:port" with a
+	// single colon carries a port to split off; a bracket-less IPv6 literal
+	// (multiple colons, no brackets) is left untouched and passed through
+	// to common.Address as-is.
+	port := c.Port
+	switch {
+	case strings.HasPrefix(host, "["):
+		end := strings.Index(host, "]")
+		if end < 0 {
+			return newError("invalid nameserver address: ", raw)
+		}
+		literal := host[1:end]
+		if remainder := host[end+1:]; strings.HasPrefix(remainder, ":") {
+			p, err := strconv.ParseUint(remainder[1:], 10, 16)
+			if err != nil {
+				return newError("invalid nameserver port: ", raw).Base(err)
+			}
+			port = uint16(p)
+		}
+		host = literal
+	case strings.Count(host, ":") == 1:
+		idx := strings.LastIndex(host, ":")
+		if p, err := strconv.ParseUint(host[idx+1:], 10, 16); err == nil {
+			host = host[:idx]
+			port = uint16(p)
+		}
+	}
+	if port == 0 {
+		port = defaultPortForTransport(c.transport)
+	}
+	c.Port = port
+
+	hostJSON, err := json.Marshal(host)
+	if err != nil {
+		return newError("invalid nameserver address: ", raw).Base(err)
+	}
 	var address common.Address
-	if err := json.Unmarshal(data, &address); err == nil {
-		c.Address = &address
-		return nil
+	if err := json.Unmarshal(hostJSON, &address); err != nil {
+		return newError("invalid nameserver address: ", raw).Base(err)
+	}
+	c.Address = &address
+	return nil
+}
+
+func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
+	var rawAddress string
+	if err := json.Unmarshal(data, &rawAddress); err == nil {
+		return c.parseAddressString(rawAddress)
 	}
 
 	var advanced struct {
-		Address   *common.Address   `json:"address"`
-		Port      uint16            `json:"port"`
-		Domains   []string          `json:"domains"`
-		ExpectIPs common.StringList `json:"expectIps"`
+		Address         json.RawMessage   `json:"address"`
+		Port            uint16            `json:"port"`
+		Domains         []string          `json:"domains"`
+		ExpectIPs       common.StringList `json:"expectIps"`
+		FakeDNS         *FakeDNSConfig    `json:"fakedns"`
+		Tag             string            `json:"tag"`
+		QueryStrategy   string            `json:"queryStrategy"`
+		SkipFallback    bool              `json:"skipFallback"`
+		DisableFallback bool              `json:"disableFallback"`
 	}
 	if err := json.Unmarshal(data, &advanced); err == nil {
-		c.Address = advanced.Address
 		c.Port = advanced.Port
 		c.Domains = advanced.Domains
 		c.ExpectIPs = advanced.ExpectIPs
+		c.FakeDNS = advanced.FakeDNS
+		c.Tag = advanced.Tag
+		c.QueryStrategy = advanced.QueryStrategy
+		// disableFallback is kept as an alias of skipFallback for readability
+		// when a server is meant to be authoritative for its domain list.
+		c.SkipFallback = advanced.SkipFallback || advanced.DisableFallback
+
+		var addressStr string
+		if err := json.Unmarshal(advanced.Address, &addressStr); err == nil {
+			return c.parseAddressString(addressStr)
+		}
+
+		var address common.Address
+		if err := json.Unmarshal(advanced.Address, &address); err != nil {
+			return newError("invalid nameserver address: ", string(advanced.Address)).Base(err)
+		}
+		c.Address = &address
 		return nil
 	}
 
 	return newError("failed to parse name server: ", string(data))
 }
 
+// parseQueryStrategy maps the JSON query strategy string to its proto enum,
+// defaulting to USE_IP for an empty or unrecognized value. It is shared by
+// DNSConfig and NameServerConfig so a server can override the global
+// strategy with its own.
+func parseQueryStrategy(queryStrategy string) dns.QueryStrategy {
+	switch strings.ToLower(queryStrategy) {
+	case "useip", "use_ip", "use-ip":
+		return dns.QueryStrategy_USE_IP
+	case "useip4", "useipv4", "use_ip4", "use_ipv4", "use_ip_v4", "use-ip4", "use-ipv4", "use-ip-v4":
+		return dns.QueryStrategy_USE_IP4
+	case "useip6", "useipv6", "use_ip6", "use_ipv6", "use_ip_v6", "use-ip6", "use-ipv6", "use-ip-v6":
+		return dns.QueryStrategy_USE_IP6
+	default:
+		return dns.QueryStrategy_USE_IP
+	}
+}
+
+// parseQueryStrategyOverride is like parseQueryStrategy, except it leaves an
+// empty value as dns.QueryStrategy_UNSPECIFIED instead of defaulting it to
+// USE_IP. It is used for a NameServer's own strategy, which should fall back
+// to the Config's global strategy when left unset, rather than silently
+// becoming indistinguishable from an explicit "UseIP" override.
+func parseQueryStrategyOverride(queryStrategy string) dns.QueryStrategy {
+	if queryStrategy == "" {
+		return dns.QueryStrategy_UNSPECIFIED
+	}
+	return parseQueryStrategy(queryStrategy)
+}
+
 func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 	if c.Address == nil {
 		return nil, newError("NameServer address is not specified.")
@@ -77,38 +231,91 @@ func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 		return nil, newError("invalid IP rule: ", c.ExpectIPs).Base(err)
 	}
 
+	var fakeDNSPools []*dns.FakeDnsPool
+	if c.FakeDNS != nil {
+		fakeDNSPools, err = c.FakeDNS.Build()
+		if err != nil {
+			return nil, newError("invalid fakedns config").Base(err)
+		}
+	}
+
+	// DoQ rides over QUIC, which is UDP-based, so only TCP and DoT/DoH (both
+	// carried over a TCP-like stream) get Network_TCP here.
+	network := net.Network_UDP
+	if c.transport == dns.NameServer_TCP || c.transport == dns.NameServer_DOT || c.transport == dns.NameServer_DOH {
+		network = net.Network_TCP
+	}
+
 	return &dns.NameServer{
 		Address: &net.Endpoint{
-			Network: net.Network_UDP,
+			Network: network,
 			Address: c.Address.Build(),
 			Port:    uint32(c.Port),
 		},
 		PrioritizedDomain: domains,
 		Geoip:             geoipList,
 		OriginalRules:     originalRules,
+		FakeDns:           fakeDNSPools,
+		Tag:               c.Tag,
+		QueryStrategy:     parseQueryStrategyOverride(c.QueryStrategy),
+		SkipFallback:      c.SkipFallback,
+		Transport:         c.transport,
+		Local:             c.local,
+		Path:              c.path,
 	}, nil
 }
 
+// HostAddress is a JSON serializable object for a hosts entry. It accepts
+// either a single address (the legacy behavior) or an array of addresses,
+// allowing a host to resolve to a mix of IPv4, IPv6 and/or a proxied domain.
+type HostAddress struct {
+	addrs []*common.Address
+}
+
+func (h *HostAddress) UnmarshalJSON(data []byte) error {
+	var addr common.Address
+	if err := json.Unmarshal(data, &addr); err == nil {
+		h.addrs = []*common.Address{&addr}
+		return nil
+	}
+
+	var addrs []*common.Address
+	if err := json.Unmarshal(data, &addrs); err == nil {
+		if len(addrs) == 0 {
+			return newError("empty address list in hosts entry")
+		}
+		h.addrs = addrs
+		return nil
+	}
+
+	return newError("failed to parse host address: ", string(data))
+}
+
 // DNSConfig is a JSON serializable object for dns.Config.
 type DNSConfig struct {
-	Servers       []*NameServerConfig        `json:"servers"`
-	Hosts         map[string]*common.Address `json:"hosts"`
-	ClientIP      *common.Address            `json:"clientIp"`
-	Tag           string                     `json:"tag"`
-	QueryStrategy string                     `json:"queryStrategy"`
-	DisableCache  bool                       `json:"disableCache"`
+	Servers       []*NameServerConfig     `json:"servers"`
+	Hosts         map[string]*HostAddress `json:"hosts"`
+	ClientIP      *common.Address         `json:"clientIp"`
+	Tag           string                  `json:"tag"`
+	QueryStrategy string                  `json:"queryStrategy"`
+	DisableCache  bool                    `json:"disableCache"`
+	FakeDNS       *FakeDNSConfig          `json:"fakedns"`
 }
 
-func getHostMapping(addr *common.Address) *dns.Config_HostMapping {
-	if addr.Family().IsIP() {
-		return &dns.Config_HostMapping{
-			Ip: [][]byte{[]byte(addr.IP())},
-		}
-	} else {
-		return &dns.Config_HostMapping{
-			ProxiedDomain: addr.Domain(),
+func getHostMapping(addrs *HostAddress) *dns.Config_HostMapping {
+	mapping := new(dns.Config_HostMapping)
+	if addrs == nil {
+		return mapping
+	}
+	for _, addr := range addrs.addrs {
+		if addr.Family().IsIP() {
+			mapping.Ip = append(mapping.Ip, []byte(addr.IP()))
+		} else {
+			// Only one proxied domain is supported per host entry; the last one wins.
+			mapping.ProxiedDomain = addr.Domain()
 		}
 	}
+	return mapping
 }
 
 // Build implements Buildable
@@ -125,15 +332,7 @@ func (c *DNSConfig) Build() (*dns.Config, error) {
 		config.ClientIp = []byte(c.ClientIP.IP())
 	}
 
-	config.QueryStrategy = dns.QueryStrategy_USE_IP
-	switch strings.ToLower(c.QueryStrategy) {
-	case "useip", "use_ip", "use-ip":
-		config.QueryStrategy = dns.QueryStrategy_USE_IP
-	case "useip4", "useipv4", "use_ip4", "use_ipv4", "use_ip_v4", "use-ip4", "use-ipv4", "use-ip-v4":
-		config.QueryStrategy = dns.QueryStrategy_USE_IP4
-	case "useip6", "useipv6", "use_ip6", "use_ipv6", "use_ip_v6", "use-ip6", "use-ipv6", "use-ip-v6":
-		config.QueryStrategy = dns.QueryStrategy_USE_IP6
-	}
+	config.QueryStrategy = parseQueryStrategy(c.QueryStrategy)
 
 	for _, server := range c.Servers {
 		ns, err := server.Build()
@@ -143,6 +342,14 @@ func (c *DNSConfig) Build() (*dns.Config, error) {
 		config.NameServer = append(config.NameServer, ns)
 	}
 
+	if c.FakeDNS != nil {
+		fakeDNSPools, err := c.FakeDNS.Build()
+		if err != nil {
+			return nil, newError("invalid fakedns config").Base(err)
+		}
+		config.FakeDns = fakeDNSPools
+	}
+
 	if c.Hosts != nil && len(c.Hosts) > 0 {
 		domains := make([]string, 0, len(c.Hosts))
 		for domain := range c.Hosts {
@@ -151,106 +358,143 @@ func (c *DNSConfig) Build() (*dns.Config, error) {
 		sort.Strings(domains)
 
 		for _, domain := range domains {
-			addr := c.Hosts[domain]
-			var mappings []*dns.Config_HostMapping
-			switch {
-			case strings.HasPrefix(domain, "domain:"):
-				domainName := domain[7:]
-				if len(domainName) == 0 {
-					return nil, newError("empty domain type of rule: ", domain)
-				}
-				mapping := getHostMapping(addr)
-				mapping.Type = dm.MatchingType_Subdomain
-				mapping.Domain = domainName
-				mappings = append(mappings, mapping)
-
-			case strings.HasPrefix(domain, "geosite:"):
-				listName := domain[8:]
-				if len(listName) == 0 {
-					return nil, newError("empty geosite rule: ", domain)
-				}
-				domains, err := geosite.LoadGeositeWithAttr("geosite.dat", listName)
-				if err != nil {
-					return nil, newError("failed to load geosite: ", listName).Base(err)
-				}
-				for _, d := range domains {
-					mapping := getHostMapping(addr)
-					mapping.Type = d.Type
-					mapping.Domain = d.Value
-					mappings = append(mappings, mapping)
-				}
-
-			case strings.HasPrefix(domain, "regexp:"):
-				regexpVal := domain[7:]
-				if len(regexpVal) == 0 {
-					return nil, newError("empty regexp type of rule: ", domain)
-				}
-				mapping := getHostMapping(addr)
-				mapping.Type = dm.MatchingType_Regex
-				mapping.Domain = regexpVal
-				mappings = append(mappings, mapping)
-
-			case strings.HasPrefix(domain, "keyword:"):
-				keywordVal := domain[8:]
-				if len(keywordVal) == 0 {
-					return nil, newError("empty keyword type of rule: ", domain)
-				}
-				mapping := getHostMapping(addr)
-				mapping.Type = dm.MatchingType_Keyword
-				mapping.Domain = keywordVal
-				mappings = append(mappings, mapping)
-
-			case strings.HasPrefix(domain, "full:"):
-				fullVal := domain[5:]
-				if len(fullVal) == 0 {
-					return nil, newError("empty full domain type of rule: ", domain)
-				}
-				mapping := getHostMapping(addr)
-				mapping.Type = dm.MatchingType_Full
-				mapping.Domain = fullVal
-				mappings = append(mappings, mapping)
-
-			case strings.HasPrefix(domain, "dotless:"):
-				mapping := getHostMapping(addr)
-				mapping.Type = dm.MatchingType_Regex
-				switch substr := domain[8:]; {
-				case substr == "":
-					mapping.Domain = "^[^.]*$"
-				case !strings.Contains(substr, "."):
-					mapping.Domain = "^[^.]*" + substr + "[^.]*$"
-				default:
-					return nil, newError("substr in dotless rule should not contain a dot: ", substr)
-				}
-				mappings = append(mappings, mapping)
-
-			case strings.HasPrefix(domain, "ext:"):
-				kv := strings.Split(domain[4:], ":")
-				if len(kv) != 2 {
-					return nil, newError("invalid external resource: ", domain)
-				}
-				filename := kv[0]
-				list := kv[1]
-				domains, err := geosite.LoadGeositeWithAttr(filename, list)
-				if err != nil {
-					return nil, newError("failed to load domain list: ", list, " from ", filename).Base(err)
-				}
-				for _, d := range domains {
-					mapping := getHostMapping(addr)
-					mapping.Type = d.Type
-					mapping.Domain = d.Value
-					mappings = append(mappings, mapping)
-				}
-
-			default:
-				mapping := getHostMapping(addr)
-				mapping.Type = dm.MatchingType_Full
-				mapping.Domain = domain
-				mappings = append(mappings, mapping)
+			mappings, err := buildHostMappings(domain, c.Hosts[domain])
+			if err != nil {
+				return nil, err
 			}
-
 			config.StaticHosts = append(config.StaticHosts, mappings...)
 		}
 	}
 
 	return config, nil
 }
+
+// buildHostMappings expands a single "hosts" entry (either from the
+// top-level map or a line read from an ext-hosts:/hosts: source) into the
+// HostMapping(s) it describes, resolving the domain:/geosite:/regexp:/
+// keyword:/full:/dotless:/ext: prefixes understood by the hosts map.
+func buildHostMappings(domain string, addr *HostAddress) ([]*dns.Config_HostMapping, error) {
+	if addr == nil {
+		return nil, newError("empty address for hosts entry: ", domain)
+	}
+
+	var mappings []*dns.Config_HostMapping
+	switch {
+	case strings.HasPrefix(domain, "domain:"):
+		domainName := domain[7:]
+		if len(domainName) == 0 {
+			return nil, newError("empty domain type of rule: ", domain)
+		}
+		mapping := getHostMapping(addr)
+		mapping.Type = dm.MatchingType_Subdomain
+		mapping.Domain = domainName
+		mappings = append(mappings, mapping)
+
+	case strings.HasPrefix(domain, "geosite:"):
+		listName := domain[8:]
+		if len(listName) == 0 {
+			return nil, newError("empty geosite rule: ", domain)
+		}
+		geositeDomains, err := geosite.LoadGeositeWithAttr("geosite.dat", listName)
+		if err != nil {
+			return nil, newError("failed to load geosite: ", listName).Base(err)
+		}
+		for _, d := range geositeDomains {
+			mapping := getHostMapping(addr)
+			mapping.Type = d.Type
+			mapping.Domain = d.Value
+			mappings = append(mappings, mapping)
+		}
+
+	case strings.HasPrefix(domain, "regexp:"):
+		regexpVal := domain[7:]
+		if len(regexpVal) == 0 {
+			return nil, newError("empty regexp type of rule: ", domain)
+		}
+		mapping := getHostMapping(addr)
+		mapping.Type = dm.MatchingType_Regex
+		mapping.Domain = regexpVal
+		mappings = append(mappings, mapping)
+
+	case strings.HasPrefix(domain, "keyword:"):
+		keywordVal := domain[8:]
+		if len(keywordVal) == 0 {
+			return nil, newError("empty keyword type of rule: ", domain)
+		}
+		mapping := getHostMapping(addr)
+		mapping.Type = dm.MatchingType_Keyword
+		mapping.Domain = keywordVal
+		mappings = append(mappings, mapping)
+
+	case strings.HasPrefix(domain, "full:"):
+		fullVal := domain[5:]
+		if len(fullVal) == 0 {
+			return nil, newError("empty full domain type of rule: ", domain)
+		}
+		mapping := getHostMapping(addr)
+		mapping.Type = dm.MatchingType_Full
+		mapping.Domain = fullVal
+		mappings = append(mappings, mapping)
+
+	case strings.HasPrefix(domain, "dotless:"):
+		mapping := getHostMapping(addr)
+		mapping.Type = dm.MatchingType_Regex
+		switch substr := domain[8:]; {
+		case substr == "":
+			mapping.Domain = "^[^.]*$"
+		case !strings.Contains(substr, "."):
+			mapping.Domain = "^[^.]*" + substr + "[^.]*$"
+		default:
+			return nil, newError("substr in dotless rule should not contain a dot: ", substr)
+		}
+		mappings = append(mappings, mapping)
+
+	case strings.HasPrefix(domain, "ext-hosts:"):
+		filename := domain[10:]
+		if len(filename) == 0 {
+			return nil, newError("empty ext-hosts filename: ", domain)
+		}
+		extMappings, err := loadExtHostsFile(filename)
+		if err != nil {
+			return nil, newError("failed to load ext-hosts file: ", filename).Base(err)
+		}
+		mappings = append(mappings, extMappings...)
+
+	case strings.HasPrefix(domain, "hosts:"):
+		tag := domain[6:]
+		if len(tag) == 0 {
+			return nil, newError("empty hosts resource tag: ", domain)
+		}
+		extMappings, err := loadExtHostsResource(tag)
+		if err != nil {
+			return nil, newError("failed to load hosts resource: ", tag).Base(err)
+		}
+		mappings = append(mappings, extMappings...)
+
+	case strings.HasPrefix(domain, "ext:"):
+		kv := strings.Split(domain[4:], ":")
+		if len(kv) != 2 {
+			return nil, newError("invalid external resource: ", domain)
+		}
+		filename := kv[0]
+		list := kv[1]
+		extDomains, err := geosite.LoadGeositeWithAttr(filename, list)
+		if err != nil {
+			return nil, newError("failed to load domain list: ", list, " from ", filename).Base(err)
+		}
+		for _, d := range extDomains {
+			mapping := getHostMapping(addr)
+			mapping.Type = d.Type
+			mapping.Domain = d.Value
+			mappings = append(mappings, mapping)
+		}
+
+	default:
+		mapping := getHostMapping(addr)
+		mapping.Type = dm.MatchingType_Full
+		mapping.Domain = domain
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, nil
+}
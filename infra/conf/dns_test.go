@@ -0,0 +1,111 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/xtls/xray-core/app/dns"
+	"github.com/xtls/xray-core/common/net"
+)
+
+func TestParseAddressStringSchemes(t *testing.T) {
+	cases := []struct {
+		raw       string
+		transport dns.NameServer_Transport
+		local     bool
+		host      string
+		port      uint16
+		path      string
+	}{
+		{"8.8.8.8", dns.NameServer_UDP, false, "8.8.8.8", 53, ""},
+		{"tcp://8.8.8.8", dns.NameServer_TCP, false, "8.8.8.8", 53, ""},
+		{"tls://dns.google", dns.NameServer_DOT, false, "dns.google", 853, ""},
+		{"tls://dns.google:8853", dns.NameServer_DOT, false, "dns.google", 8853, ""},
+		{"https://dns.google/dns-query", dns.NameServer_DOH, false, "dns.google", 443, "/dns-query"},
+		{"quic+local://dns.example.com", dns.NameServer_DOQ, true, "dns.example.com", 853, ""},
+		// Bracket-less IPv6 literal: must be passed through untouched, not
+		// mangled by a naive "split on the last colon" port parse.
+		// Address.String() renders an IPv6 address in brackets.
+		{"2606:4700:4700::1111", dns.NameServer_UDP, false, "[2606:4700:4700::1111]", 53, ""},
+		{"tcp://[2606:4700:4700::1111]:5353", dns.NameServer_TCP, false, "[2606:4700:4700::1111]", 5353, ""},
+	}
+
+	for _, tc := range cases {
+		c := &NameServerConfig{}
+		if err := c.parseAddressString(tc.raw); err != nil {
+			t.Fatalf("parseAddressString(%q): %v", tc.raw, err)
+		}
+		if c.transport != tc.transport {
+			t.Errorf("%q: expected transport %v, got %v", tc.raw, tc.transport, c.transport)
+		}
+		if c.local != tc.local {
+			t.Errorf("%q: expected local=%v, got %v", tc.raw, tc.local, c.local)
+		}
+		if c.Address.String() != tc.host {
+			t.Errorf("%q: expected host %q, got %q", tc.raw, tc.host, c.Address.String())
+		}
+		if c.Port != tc.port {
+			t.Errorf("%q: expected port %d, got %d", tc.raw, tc.port, c.Port)
+		}
+		if c.path != tc.path {
+			t.Errorf("%q: expected path %q, got %q", tc.raw, tc.path, c.path)
+		}
+	}
+}
+
+func TestNameServerConfigBuildQueryStrategyOverride(t *testing.T) {
+	// An unset per-server queryStrategy must build to QueryStrategy_UNSPECIFIED,
+	// not QueryStrategy_USE_IP, so it stays distinguishable from a server that
+	// explicitly asked for USE_IP.
+	cases := []struct {
+		queryStrategy string
+		want          dns.QueryStrategy
+	}{
+		{"", dns.QueryStrategy_UNSPECIFIED},
+		{"UseIP", dns.QueryStrategy_USE_IP},
+		{"UseIPv4", dns.QueryStrategy_USE_IP4},
+		{"UseIPv6", dns.QueryStrategy_USE_IP6},
+	}
+
+	for _, tc := range cases {
+		c := &NameServerConfig{QueryStrategy: tc.queryStrategy}
+		if err := c.parseAddressString("8.8.8.8"); err != nil {
+			t.Fatalf("parseAddressString: %v", err)
+		}
+		ns, err := c.Build()
+		if err != nil {
+			t.Fatalf("Build(%q): %v", tc.queryStrategy, err)
+		}
+		if ns.QueryStrategy != tc.want {
+			t.Errorf("queryStrategy %q: expected %v, got %v", tc.queryStrategy, tc.want, ns.QueryStrategy)
+		}
+	}
+}
+
+func TestNameServerConfigBuildNetwork(t *testing.T) {
+	// DoQ rides over QUIC (UDP), so it must not be built as Network_TCP even
+	// though every other non-UDP transport here is.
+	cases := []struct {
+		raw     string
+		network net.Network
+	}{
+		{"8.8.8.8", net.Network_UDP},
+		{"tcp://8.8.8.8", net.Network_TCP},
+		{"tls://dns.google", net.Network_TCP},
+		{"https://dns.google/dns-query", net.Network_TCP},
+		{"quic://dns.google", net.Network_UDP},
+	}
+
+	for _, tc := range cases {
+		c := &NameServerConfig{}
+		if err := c.parseAddressString(tc.raw); err != nil {
+			t.Fatalf("parseAddressString(%q): %v", tc.raw, err)
+		}
+		ns, err := c.Build()
+		if err != nil {
+			t.Fatalf("Build(%q): %v", tc.raw, err)
+		}
+		if ns.Address.Network != tc.network {
+			t.Errorf("%q: expected network %v, got %v", tc.raw, tc.network, ns.Address.Network)
+		}
+	}
+}
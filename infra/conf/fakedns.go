@@ -0,0 +1,60 @@
+package conf
+
+import (
+	"encoding/json"
+
+	"github.com/xtls/xray-core/app/dns"
+)
+
+// FakeDNSPoolElementConfig is a single fake IP pool: a CIDR to allocate
+// addresses from and the maximum number of live domain<->IP mappings to
+// keep for it.
+type FakeDNSPoolElementConfig struct {
+	IPPool  string `json:"ipPool"`
+	LRUSize int64  `json:"poolSize"`
+}
+
+// FakeDNSConfig is a JSON serializable object for one or more fake DNS
+// pools. It accepts either a single pool object (the common case) or an
+// array of pools, e.g. one for IPv4 and one for IPv6.
+type FakeDNSConfig struct {
+	pools []*FakeDNSPoolElementConfig
+}
+
+func (f *FakeDNSConfig) UnmarshalJSON(data []byte) error {
+	var pool FakeDNSPoolElementConfig
+	if err := json.Unmarshal(data, &pool); err == nil {
+		f.pools = []*FakeDNSPoolElementConfig{&pool}
+		return nil
+	}
+
+	var pools []*FakeDNSPoolElementConfig
+	if err := json.Unmarshal(data, &pools); err == nil {
+		if len(pools) == 0 {
+			return newError("empty fakedns pool list")
+		}
+		f.pools = pools
+		return nil
+	}
+
+	return newError("failed to parse fakedns config: ", string(data))
+}
+
+// Build converts the config into the pool descriptors understood by
+// app/dns when it constructs its fakedns.HolderMulti.
+func (f *FakeDNSConfig) Build() ([]*dns.FakeDnsPool, error) {
+	pools := make([]*dns.FakeDnsPool, 0, len(f.pools))
+	for _, pool := range f.pools {
+		if pool.IPPool == "" {
+			return nil, newError("fakedns pool is missing ipPool")
+		}
+		if pool.LRUSize <= 0 {
+			return nil, newError("fakedns pool size must be positive: ", pool.LRUSize)
+		}
+		pools = append(pools, &dns.FakeDnsPool{
+			IpPool:  pool.IPPool,
+			LruSize: pool.LRUSize,
+		})
+	}
+	return pools, nil
+}
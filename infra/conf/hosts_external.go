@@ -0,0 +1,104 @@
+package conf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/xtls/xray-core/app/dns"
+)
+
+// hostsResources holds named host-mapping sources registered under a tag
+// through RegisterHostsResource, so that a "hosts:tag" entry in the hosts map can resolve to it without
+// touching the filesystem directly. hostsResourcesMutex guards both, since
+// registration and lookup aren't guaranteed to stay confined to a single
+// parse pass (e.g. config hot-reload registering while another load reads).
+var (
+	hostsResourcesMutex sync.RWMutex
+	hostsResources      = make(map[string][]byte)
+)
+
+// RegisterHostsResource makes data available to any "hosts:tag" entry under
+// the given tag. It is expected to be called while the rest of the config is
+// being parsed, before DNSConfig.Build runs.
+func RegisterHostsResource(tag string, data []byte) {
+	hostsResourcesMutex.Lock()
+	defer hostsResourcesMutex.Unlock()
+	hostsResources[tag] = data
+}
+
+func loadExtHostsFile(filename string) ([]*dns.Config_HostMapping, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, newError("failed to read ext-hosts file: ", filename).Base(err)
+	}
+	return parseExtHosts(data)
+}
+
+func loadExtHostsResource(tag string) ([]*dns.Config_HostMapping, error) {
+	hostsResourcesMutex.RLock()
+	data, found := hostsResources[tag]
+	hostsResourcesMutex.RUnlock()
+	if !found {
+		return nil, newError("hosts resource not found: ", tag)
+	}
+	return parseExtHosts(data)
+}
+
+// parseExtHosts reads a real /etc/hosts-format file where each non-comment
+// line is "address hostname [hostname ...]": address is a single IP or
+// proxied domain, and every hostname after it is a key that resolves to it,
+// exactly as the OS resolver treats a hosts file. Each hostname may itself
+// carry a domain:/full:/regexp: prefix, as in the top-level "hosts" JSON map.
+func parseExtHosts(data []byte) ([]*dns.Config_HostMapping, error) {
+	var mappings []*dns.Config_HostMapping
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, newError("invalid hosts line, expected \"address hostname...\": ", line)
+		}
+
+		addr, err := newHostAddress(fields[:1])
+		if err != nil {
+			return nil, newError("invalid address in hosts line: ", line).Base(err)
+		}
+
+		for _, hostname := range fields[1:] {
+			lineMappings, err := buildHostMappings(hostname, addr)
+			if err != nil {
+				return nil, err
+			}
+			mappings = append(mappings, lineMappings...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, newError("failed to read hosts data").Base(err)
+	}
+
+	return mappings, nil
+}
+
+// newHostAddress builds a HostAddress out of plain address strings, reusing
+// HostAddress's own JSON parsing so a string like "1.2.3.4" or
+// "proxy.example.com" resolves exactly as it would in the JSON hosts map.
+func newHostAddress(values []string) (*HostAddress, error) {
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var addr HostAddress
+	if err := addr.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return &addr, nil
+}
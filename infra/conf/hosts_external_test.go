@@ -0,0 +1,33 @@
+package conf
+
+import (
+	"testing"
+)
+
+func TestParseExtHostsAddressFirst(t *testing.T) {
+	data := []byte("# comment\n127.0.0.1 a.example.com b.example.com\nproxy.example.net c.example.com\n")
+
+	mappings, err := parseExtHosts(data)
+	if err != nil {
+		t.Fatalf("parseExtHosts: %v", err)
+	}
+	if len(mappings) != 3 {
+		t.Fatalf("expected 3 mappings, got %d", len(mappings))
+	}
+
+	if mappings[0].Domain != "a.example.com" || len(mappings[0].Ip) != 1 {
+		t.Fatalf("expected a.example.com to map to a single IP, got %+v", mappings[0])
+	}
+	if mappings[1].Domain != "b.example.com" || len(mappings[1].Ip) != 1 {
+		t.Fatalf("expected b.example.com to map to a single IP, got %+v", mappings[1])
+	}
+	if mappings[2].Domain != "c.example.com" || mappings[2].ProxiedDomain != "proxy.example.net" {
+		t.Fatalf("expected c.example.com to map to the proxied domain, got %+v", mappings[2])
+	}
+}
+
+func TestParseExtHostsRejectsMissingHostname(t *testing.T) {
+	if _, err := parseExtHosts([]byte("127.0.0.1\n")); err == nil {
+		t.Fatalf("expected an error for a line with no hostname")
+	}
+}
@@ -0,0 +1,43 @@
+package conf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHostAddressUnmarshalSingle(t *testing.T) {
+	var h HostAddress
+	if err := json.Unmarshal([]byte(`"1.2.3.4"`), &h); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(h.addrs) != 1 || h.addrs[0].String() != "1.2.3.4" {
+		t.Fatalf("expected a single 1.2.3.4 address, got %+v", h.addrs)
+	}
+}
+
+func TestHostAddressUnmarshalList(t *testing.T) {
+	var h HostAddress
+	if err := json.Unmarshal([]byte(`["1.2.3.4", "::1"]`), &h); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(h.addrs) != 2 {
+		t.Fatalf("expected 2 addresses, got %d", len(h.addrs))
+	}
+}
+
+func TestHostAddressUnmarshalEmptyList(t *testing.T) {
+	var h HostAddress
+	if err := json.Unmarshal([]byte(`[]`), &h); err == nil {
+		t.Fatalf("expected an error for an empty address list")
+	}
+}
+
+func TestDNSConfigBuildRejectsNullHostsEntry(t *testing.T) {
+	var c DNSConfig
+	if err := json.Unmarshal([]byte(`{"hosts": {"foo.com": null}}`), &c); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if _, err := c.Build(); err == nil {
+		t.Fatalf("expected a clean error for a null hosts entry, not a panic")
+	}
+}